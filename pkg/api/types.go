@@ -0,0 +1,201 @@
+package api
+
+// ClusterProfile is the name of a set of credentials for claiming a cluster
+// to run a test in.
+type ClusterProfile string
+
+// ReleaseBuildConfiguration describes the full configuration for a
+// repository's CI, including how to build and test it.
+type ReleaseBuildConfiguration struct {
+	Tests []TestStepConfiguration `json:"tests,omitempty"`
+}
+
+// TestStepConfiguration holds the information needed to run one of a
+// repository's tests.
+type TestStepConfiguration struct {
+	As string `json:"as,omitempty"`
+
+	// MultiStageTestConfiguration is a test that runs as a series of steps
+	// resolved from the registry. Once resolved, it is replaced with
+	// MultiStageTestConfigurationLiteral.
+	MultiStageTestConfiguration *MultiStageTestConfiguration `json:"steps,omitempty"`
+	// MultiStageTestConfigurationLiteral is the fully resolved form of
+	// MultiStageTestConfiguration, with all registry references expanded.
+	MultiStageTestConfigurationLiteral *MultiStageTestConfigurationLiteral `json:"literal_steps,omitempty"`
+}
+
+// MultiStageTestConfiguration is a flow that will be run as a series of
+// steps, either defined directly or through the named workflow and
+// registry references it contains. It must be resolved into a
+// MultiStageTestConfigurationLiteral before it can be executed.
+type MultiStageTestConfiguration struct {
+	ClusterProfile ClusterProfile `json:"cluster_profile,omitempty"`
+	// Workflow is the name of a workflow to be used for this configuration.
+	// Any fields left unset on this configuration are filled in with the
+	// workflow's values.
+	Workflow *string    `json:"workflow,omitempty"`
+	Pre      []TestStep `json:"pre,omitempty"`
+	Test     []TestStep `json:"test,omitempty"`
+	Post     []TestStep `json:"post,omitempty"`
+
+	// Hooks selects registry hooks to wrap every step of this workflow
+	// with: each matching hook's Before steps run ahead of everything in
+	// Pre, and its After steps are spliced into Post.
+	Hooks []HookSelector `json:"hooks,omitempty"`
+
+	// WorkflowMode controls how Pre/Test/Post are combined with the
+	// sections of the named Workflow. It has no effect without a Workflow.
+	WorkflowMode WorkflowMode `json:"workflow_mode,omitempty"`
+}
+
+// WorkflowMode selects how a MultiStageTestConfiguration's own Pre/Test/Post
+// are combined with those of the workflow it names.
+type WorkflowMode string
+
+const (
+	// WorkflowModeReplace is the default: a section left nil takes the
+	// workflow's steps verbatim, and a section the user set entirely
+	// replaces the workflow's.
+	WorkflowModeReplace WorkflowMode = ""
+	// WorkflowModeMerge layers the user's steps onto the workflow's: a
+	// step naming one already in the workflow (by `as`) replaces it, a
+	// new one is appended, and `{ as: name, skip: true }` removes it.
+	WorkflowModeMerge WorkflowMode = "merge"
+)
+
+// MultiStageTestConfigurationLiteral is a MultiStageTestConfiguration after
+// the registry has fully resolved its workflow, chains and references into
+// literal steps.
+type MultiStageTestConfigurationLiteral struct {
+	ClusterProfile ClusterProfile    `json:"cluster_profile,omitempty"`
+	Pre            []LiteralTestStep `json:"pre,omitempty"`
+	Test           []LiteralTestStep `json:"test,omitempty"`
+	Post           []LiteralTestStep `json:"post,omitempty"`
+
+	// Signature is the SHA-256, in hex, of this flow's ClusterProfile and
+	// the concatenation of its steps' own Signature, in order. It changes
+	// exactly when something about what the flow actually runs changes,
+	// so callers can key caches or detect a no-op resolve across revisions.
+	Signature string `json:"signature,omitempty"`
+}
+
+// TestStep is a step of a multi-stage test. It must contain exactly one of
+// a literal step definition, a reference to a step in the registry, or a
+// reference to a chain of steps in the registry.
+type TestStep struct {
+	LiteralTestStep *LiteralTestStep `json:"literal_test_step,omitempty"`
+	Reference       *string          `json:"ref,omitempty"`
+	Chain           *string          `json:"chain,omitempty"`
+	// Foreach expands this step into one copy of Foreach.Steps per entry of
+	// Foreach.Values, substituting `${{ matrix.KEY }}` tokens in each copy.
+	// It is mutually exclusive with the other three fields.
+	Foreach *ForeachStep `json:"foreach,omitempty"`
+
+	// As and Skip are only meaningful under WorkflowModeMerge: a step
+	// with no Reference, Chain, LiteralTestStep, or Foreach, naming As
+	// and setting Skip, deletes the like-named workflow step instead of
+	// being resolved itself.
+	As   string `json:"as,omitempty"`
+	Skip bool   `json:"skip,omitempty"`
+
+	// When is an optional expression evaluated while the registry resolves
+	// this step; if it evaluates to false, the step is dropped. See
+	// registry.ParseStepCondition for the supported grammar.
+	When string `json:"when,omitempty"`
+}
+
+// ForeachStep drives a parameter sweep: Steps is resolved once per entry in
+// Values, with every `${{ matrix.KEY }}` token in the resolved steps
+// replaced by the value of KEY in that entry.
+type ForeachStep struct {
+	Values []map[string]string `json:"values"`
+	Steps  []TestStep          `json:"steps"`
+}
+
+// LiteralTestStep is a fully defined step in a test, with no further
+// indirection through the registry.
+type LiteralTestStep struct {
+	As        string                   `json:"as"`
+	From      string                   `json:"from,omitempty"`
+	FromImage *ImageStreamTagReference `json:"from_image,omitempty"`
+	Commands  string                   `json:"commands,omitempty"`
+	Resources ResourceRequirements     `json:"resources,omitempty"`
+
+	// Environment declares the step's environment variables and, for each,
+	// an optional default value.
+	Environment []StepParameter `json:"env,omitempty"`
+
+	Credentials  []CredentialReference `json:"credentials,omitempty"`
+	Dependencies []StepDependency      `json:"dependencies,omitempty"`
+
+	// Hooks selects registry hooks to wrap this one step with: each
+	// matching hook's Before steps run immediately ahead of this step, and
+	// its After steps are spliced into Post.
+	Hooks []HookSelector `json:"hooks,omitempty"`
+
+	// When is an optional expression evaluated while the registry resolves
+	// this step; if it evaluates to false, the step is dropped. It is
+	// checked in addition to, not instead of, the When set on the TestStep
+	// that referenced this step.
+	When string `json:"when,omitempty"`
+
+	// Signature is the SHA-256, in hex, of this step's non-cosmetic
+	// fields: Commands, From/FromImage, Resources, Env, Credentials, and
+	// Dependencies. It is computed by the registry while resolving the
+	// step and is not meant to be set in source configuration.
+	Signature string `json:"signature,omitempty"`
+}
+
+// ImageStreamTagReference identifies an image to run a step's commands in.
+type ImageStreamTagReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Tag       string `json:"tag"`
+}
+
+// ResourceRequirements mirrors Kubernetes' resource requests and limits,
+// keyed by resource name (e.g. "cpu", "memory").
+type ResourceRequirements struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// CredentialReference mounts a secret from another namespace into a step.
+type CredentialReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	MountPath string `json:"mount_path"`
+}
+
+// StepDependency exposes the pull spec of another step's output image to a
+// step, through the named environment variable.
+type StepDependency struct {
+	Name string `json:"name"`
+	Env  string `json:"env"`
+}
+
+// StepParameter is an environment variable declared by a step, with an
+// optional default value used when the executing config does not set one.
+type StepParameter struct {
+	Name    string  `json:"name"`
+	Default *string `json:"default,omitempty"`
+}
+
+// Hook is a registry entry attaching cross-cutting Before/After steps to
+// whatever workflows or steps select it, so operators don't have to
+// copy-paste things like must-gather or artifact upload into every
+// workflow's Post.
+type Hook struct {
+	// Labels are matched against a HookSelector's Labels; a selector
+	// matches the hook if every one of its Labels is present here.
+	Labels map[string]string `json:"labels,omitempty"`
+	Before []TestStep        `json:"before,omitempty"`
+	After  []TestStep        `json:"after,omitempty"`
+}
+
+// HookSelector names a Hook directly, or matches one or more hooks sharing
+// a set of labels.
+type HookSelector struct {
+	Name   string            `json:"name,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
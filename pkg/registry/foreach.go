@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// matrixToken matches `${{ matrix.KEY }}` placeholders substituted by
+// expandForeach.
+var matrixToken = regexp.MustCompile(`\$\{\{\s*matrix\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// expandForeach replaces every Foreach step in steps with the resolved
+// expansion of its inner steps, one copy per entry in Foreach.Values. Each
+// copy has its `${{ matrix.KEY }}` tokens substituted before any nested
+// chain or reference it contains is unrolled and dereferenced, so a matrix
+// value may itself select which registry entry to use; the resulting As is
+// suffixed with the (stable) index of the Values entry it came from so that
+// checkForDuplicates still passes. The wrapping step's own When is
+// evaluated here, before expansion: like a chain step's, its contents are
+// discarded by the expansion, so there is nowhere else to honor it.
+func (r *registry) expandForeach(steps []api.TestStep, vars map[string]string) (out []api.TestStep, errs []error) {
+	for _, step := range steps {
+		if step.Foreach == nil {
+			out = append(out, step)
+			continue
+		}
+		if active, err := evalWhen(step.When, vars); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !active {
+			continue
+		}
+		for i, values := range step.Foreach.Values {
+			substituted := make([]api.TestStep, len(step.Foreach.Steps))
+			for j, inner := range step.Foreach.Steps {
+				substituted[j] = substituteMatrix(inner, values)
+			}
+			literalSteps, innerErrs := r.process(substituted, vars)
+			errs = append(errs, innerErrs...)
+			for _, literal := range literalSteps {
+				literal.As = fmt.Sprintf("%s-%d", literal.As, i)
+				out = append(out, api.TestStep{LiteralTestStep: &literal})
+			}
+		}
+	}
+	return out, errs
+}
+
+// substituteMatrix returns a copy of step with every `${{ matrix.KEY }}`
+// token in its Reference, Chain, When, and (if literal) As, Commands,
+// When and Environment defaults replaced by values[KEY]. Tokens naming an
+// unknown key are left untouched.
+func substituteMatrix(step api.TestStep, values map[string]string) api.TestStep {
+	step.Reference = substituteMatrixStringPtr(step.Reference, values)
+	step.Chain = substituteMatrixStringPtr(step.Chain, values)
+	step.When = substituteMatrixString(step.When, values)
+	if step.LiteralTestStep != nil {
+		literal := *step.LiteralTestStep
+		literal.As = substituteMatrixString(literal.As, values)
+		literal.Commands = substituteMatrixString(literal.Commands, values)
+		literal.When = substituteMatrixString(literal.When, values)
+		if literal.Environment != nil {
+			env := make([]api.StepParameter, len(literal.Environment))
+			for i, e := range literal.Environment {
+				e.Default = substituteMatrixStringPtr(e.Default, values)
+				env[i] = e
+			}
+			literal.Environment = env
+		}
+		step.LiteralTestStep = &literal
+	}
+	return step
+}
+
+func substituteMatrixString(s string, values map[string]string) string {
+	return matrixToken.ReplaceAllStringFunc(s, func(match string) string {
+		name := matrixToken.FindStringSubmatch(match)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+func substituteMatrixStringPtr(s *string, values map[string]string) *string {
+	if s == nil {
+		return nil
+	}
+	substituted := substituteMatrixString(*s, values)
+	return &substituted
+}
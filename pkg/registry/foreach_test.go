@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestExpandForeachSubstitutesAndSuffixesIndex(t *testing.T) {
+	r := &registry{}
+	steps := []api.TestStep{
+		{
+			Foreach: &api.ForeachStep{
+				Values: []map[string]string{{"NAME": "a"}, {"NAME": "b"}},
+				Steps: []api.TestStep{
+					{LiteralTestStep: &api.LiteralTestStep{As: "run-${{ matrix.NAME }}", Commands: "echo ${{ matrix.NAME }}"}},
+				},
+			},
+		},
+	}
+
+	out, errs := r.expandForeach(steps, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	var got []string
+	for _, step := range out {
+		if step.LiteralTestStep == nil {
+			t.Fatalf("expected only literal steps out of expandForeach, got %+v", step)
+		}
+		got = append(got, step.LiteralTestStep.As)
+	}
+	if want := []string{"run-a-0", "run-b-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("As names = %v, want %v", got, want)
+	}
+}
+
+func TestExpandForeachSubstitutesReference(t *testing.T) {
+	r := &registry{
+		stepsByName: ReferenceByName{
+			"unit-test-a": {As: "unit-test-a", Commands: "make test-a"},
+			"unit-test-b": {As: "unit-test-b", Commands: "make test-b"},
+		},
+	}
+	steps := []api.TestStep{
+		{
+			Foreach: &api.ForeachStep{
+				Values: []map[string]string{{"SUFFIX": "a"}, {"SUFFIX": "b"}},
+				Steps: []api.TestStep{
+					{Reference: strPtr("unit-test-${{ matrix.SUFFIX }}")},
+				},
+			},
+		},
+	}
+
+	out, errs := r.expandForeach(steps, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	var got []string
+	for _, step := range out {
+		got = append(got, step.LiteralTestStep.Commands)
+	}
+	if want := []string{"make test-a", "make test-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands = %v, want %v", got, want)
+	}
+}
+
+func TestExpandForeachHonorsWhenOnTheForeachStep(t *testing.T) {
+	r := &registry{}
+	step := api.TestStep{
+		When: `profile == "aws"`,
+		Foreach: &api.ForeachStep{
+			Values: []map[string]string{{"NAME": "a"}},
+			Steps:  []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "run-${{ matrix.NAME }}"}}},
+		},
+	}
+
+	skipped, errs := r.expandForeach([]api.TestStep{step}, map[string]string{"profile": "gcp"})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("expected the foreach to be skipped when its When is false, got %+v", skipped)
+	}
+
+	included, errs := r.expandForeach([]api.TestStep{step}, map[string]string{"profile": "aws"})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(included) != 1 || included[0].LiteralTestStep == nil || included[0].LiteralTestStep.As != "run-a-0" {
+		t.Errorf("expected the foreach to expand when its When is true, got %+v", included)
+	}
+}
+
+func TestSubstituteMatrixLeavesUnknownTokensUntouched(t *testing.T) {
+	step := api.TestStep{When: "profile == \"${{ matrix.unknown }}\""}
+	got := substituteMatrix(step, map[string]string{"known": "value"})
+	if got.When != step.When {
+		t.Errorf("expected an unmatched matrix token to be left alone, got %q", got.When)
+	}
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// HooksByName holds the registry's Hook entries, keyed by name. Like a Go
+// map, it carries no registration order; applyHooks orders multiple
+// matching hooks lexicographically by name instead (see sortedHookNames),
+// so an operator relying on splice order should name hooks accordingly.
+type HooksByName map[string]api.Hook
+
+// ResolveTrace records which hooks fired on which step during a Resolve
+// call. Set ResolveContext.Trace to a non-nil *ResolveTrace to capture one;
+// Resolve leaves it untouched if left nil.
+type ResolveTrace struct {
+	Hooks []HookFiring
+}
+
+// HookFiring records a single hook application: hook Name fired When
+// ("before" or "after") Step, where Step is "<workflow>" for a workflow-wide
+// hook rather than one attached to a single step.
+type HookFiring struct {
+	Step string
+	Name string
+	When string
+}
+
+func (t *ResolveTrace) record(step, name, when string) {
+	if t == nil {
+		return
+	}
+	t.Hooks = append(t.Hooks, HookFiring{Step: step, Name: name, When: when})
+}
+
+// applyHooks splices every hook matching workflowSelectors or one of flow's
+// steps' own Hooks selectors into flow: Before steps land immediately ahead
+// of whatever they apply to (the start of Pre for a workflow-wide hook, or
+// immediately before the one step for a step-level hook), and After steps
+// are always appended to Post. Multiple matching hooks are ordered
+// lexicographically by name, since HooksByName has no order of its own to
+// preserve: Before steps apply in ascending name order, and After steps
+// unwind in descending name order, so the two mirror each other the way a
+// stack's push and pop order would. A hook matched on more than one site
+// (e.g. by a label selector shared across several steps) is resolved and
+// spliced in once per site, each copy's `as` suffixed with the site it was
+// attached to, so a single hook can't introduce duplicate step names.
+func (r *registry) applyHooks(flow api.MultiStageTestConfigurationLiteral, workflowSelectors []api.HookSelector, vars map[string]string, trace *ResolveTrace) (api.MultiStageTestConfigurationLiteral, []error) {
+	var errs []error
+	names := r.sortedHookNames()
+
+	resolve := func(steps []api.TestStep) []api.LiteralTestStep {
+		literal, hookErrs := r.process(steps, vars)
+		errs = append(errs, hookErrs...)
+		return literal
+	}
+
+	// attachedTo suffixes each of a hook's resolved steps with the name of
+	// the thing it was spliced in for, so a hook matched by label selector
+	// on several steps (or the workflow as a whole) doesn't inject the same
+	// `as` more than once into the same section.
+	attachedTo := func(steps []api.LiteralTestStep, site string) []api.LiteralTestStep {
+		out := make([]api.LiteralTestStep, len(steps))
+		for i, step := range steps {
+			step.As = fmt.Sprintf("%s-%s", step.As, site)
+			out[i] = step
+		}
+		return out
+	}
+
+	var postAfter []api.LiteralTestStep
+	recordAfter := func(step, name string) {
+		postAfter = append(postAfter, attachedTo(resolve(r.hooksByName[name].After), step)...)
+	}
+
+	// Workflow-wide hooks: Before goes ahead of everything in Pre, After is
+	// queued for Post. Walk names in descending order so After unwinds in
+	// the mirror image of the ascending order Before applies below.
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if matchesAny(name, r.hooksByName[name], workflowSelectors) {
+			recordAfter("<workflow>", name)
+			trace.record("<workflow>", name, "after")
+		}
+	}
+	// Walk names in descending order here too: each match is prepended
+	// ahead of whatever is already in flow.Pre, so processing them
+	// back-to-front leaves the alphabetically-first matching hook's Before
+	// steps at the very front, i.e. ascending name order overall.
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if matchesAny(name, r.hooksByName[name], workflowSelectors) {
+			before := attachedTo(resolve(r.hooksByName[name].Before), "<workflow>")
+			flow.Pre = append(append([]api.LiteralTestStep{}, before...), flow.Pre...)
+			trace.record("<workflow>", name, "before")
+		}
+	}
+
+	// Step-level hooks: Before goes immediately ahead of the step within
+	// its own section in ascending name order, and After is queued for Post
+	// in descending name order, mirroring it.
+	for _, section := range []*[]api.LiteralTestStep{&flow.Pre, &flow.Test, &flow.Post} {
+		var out []api.LiteralTestStep
+		for _, step := range *section {
+			for _, name := range names {
+				if matchesAny(name, r.hooksByName[name], step.Hooks) {
+					out = append(out, attachedTo(resolve(r.hooksByName[name].Before), step.As)...)
+					trace.record(step.As, name, "before")
+				}
+			}
+			out = append(out, step)
+			for i := len(names) - 1; i >= 0; i-- {
+				name := names[i]
+				if matchesAny(name, r.hooksByName[name], step.Hooks) {
+					recordAfter(step.As, name)
+					trace.record(step.As, name, "after")
+				}
+			}
+		}
+		*section = out
+	}
+	flow.Post = append(flow.Post, postAfter...)
+
+	return flow, errs
+}
+
+// matchesAny reports whether hook (registered as name) matches any of the
+// given selectors, either by exact name or by having every label the
+// selector asks for.
+func matchesAny(name string, hook api.Hook, selectors []api.HookSelector) bool {
+	for _, selector := range selectors {
+		if selector.Name != "" {
+			if selector.Name == name {
+				return true
+			}
+			continue
+		}
+		if len(selector.Labels) == 0 {
+			continue
+		}
+		matched := true
+		for k, v := range selector.Labels {
+			if hook.Labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedHookNames returns the registry's hook names in ascending
+// lexicographic order. HooksByName has no registration order to preserve,
+// so applyHooks uses this order as a deterministic stand-in: Before splices
+// apply in this order, and After splices unwind in the reverse of it.
+func (r *registry) sortedHookNames() []string {
+	names := make([]string, 0, len(r.hooksByName))
+	for name := range r.hooksByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func literalHookStep(as string) api.TestStep {
+	return api.TestStep{LiteralTestStep: &api.LiteralTestStep{As: as}}
+}
+
+func stepNames(steps []api.LiteralTestStep) []string {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.As)
+	}
+	return names
+}
+
+// TestApplyHooksWorkflowOrderMirrorsCleanup exercises two workflow-wide
+// hooks matching by name: setup (Before) should apply in ascending name
+// order, and cleanup (After) should unwind in descending name order, the
+// mirror image of it.
+func TestApplyHooksWorkflowOrderMirrorsCleanup(t *testing.T) {
+	r := &registry{
+		hooksByName: HooksByName{
+			"a": {Before: []api.TestStep{literalHookStep("a-before")}, After: []api.TestStep{literalHookStep("a-after")}},
+			"b": {Before: []api.TestStep{literalHookStep("b-before")}, After: []api.TestStep{literalHookStep("b-after")}},
+		},
+	}
+	flow := api.MultiStageTestConfigurationLiteral{Pre: []api.LiteralTestStep{{As: "main"}}}
+	selectors := []api.HookSelector{{Name: "a"}, {Name: "b"}}
+
+	resolved, errs := r.applyHooks(flow, selectors, nil, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if got, want := stepNames(resolved.Pre), []string{"a-before-<workflow>", "b-before-<workflow>", "main"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pre = %v, want %v", got, want)
+	}
+	if got, want := stepNames(resolved.Post), []string{"b-after-<workflow>", "a-after-<workflow>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Post = %v, want %v", got, want)
+	}
+}
+
+// TestApplyHooksLabelSelectorOnMultipleStepsDoesNotDuplicateNames covers a
+// hook matched by label on several steps in the same section, which used to
+// splice in the same unsuffixed `as` once per match.
+func TestApplyHooksLabelSelectorOnMultipleStepsDoesNotDuplicateNames(t *testing.T) {
+	r := &registry{
+		hooksByName: HooksByName{
+			"must-gather": {
+				Labels: map[string]string{"gather": "true"},
+				After:  []api.TestStep{literalHookStep("must-gather")},
+			},
+		},
+	}
+	withHook := api.HookSelector{Labels: map[string]string{"gather": "true"}}
+	flow := api.MultiStageTestConfigurationLiteral{
+		Test: []api.LiteralTestStep{
+			{As: "unit", Hooks: []api.HookSelector{withHook}},
+			{As: "e2e", Hooks: []api.HookSelector{withHook}},
+		},
+	}
+
+	resolved, errs := r.applyHooks(flow, nil, nil, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if err := checkForDuplicates(resolved.Post); err != nil {
+		t.Errorf("expected no duplicate names in Post, got: %v", err)
+	}
+	if got, want := stepNames(resolved.Post), []string{"must-gather-unit", "must-gather-e2e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Post = %v, want %v", got, want)
+	}
+}
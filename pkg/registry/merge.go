@@ -0,0 +1,131 @@
+package registry
+
+import "github.com/openshift/ci-tools/pkg/api"
+
+// mergeStepList implements api.WorkflowModeMerge: overrides is layered on
+// top of base, matching entries by their resolved `as` name. An override
+// naming a step not in base is appended; one naming a step already in base
+// replaces it in place (merging Environment and Dependencies additively
+// rather than dropping base's, see mergeStep); and `{ as: name, skip:
+// true }` removes the base step instead of replacing it.
+func (r *registry) mergeStepList(base, overrides []api.TestStep) []api.TestStep {
+	baseNames := map[string]bool{}
+	for _, step := range base {
+		if name, ok := r.testStepName(step); ok {
+			baseNames[name] = true
+		}
+	}
+
+	skip := map[string]bool{}
+	replacements := map[string]api.TestStep{}
+	var appended []api.TestStep
+	for _, override := range overrides {
+		name, ok := r.testStepName(override)
+		if !ok {
+			appended = append(appended, override)
+			continue
+		}
+		switch {
+		case override.Skip:
+			skip[name] = true
+		case baseNames[name]:
+			replacements[name] = override
+		default:
+			appended = append(appended, override)
+		}
+	}
+
+	var merged []api.TestStep
+	for _, step := range base {
+		name, ok := r.testStepName(step)
+		if ok && skip[name] {
+			continue
+		}
+		if ok {
+			if override, ok := replacements[name]; ok {
+				merged = append(merged, r.mergeStep(step, override))
+				continue
+			}
+		}
+		merged = append(merged, step)
+	}
+	return append(merged, appended...)
+}
+
+// testStepName returns the `as` name a TestStep resolves to, when that can
+// be determined without unrolling a chain or expanding a foreach: a
+// literal step's or skip marker's own As, or a reference's registry entry.
+func (r *registry) testStepName(step api.TestStep) (string, bool) {
+	switch {
+	case step.LiteralTestStep != nil:
+		return step.LiteralTestStep.As, true
+	case step.Reference != nil:
+		literal, ok := r.stepsByName[*step.Reference]
+		if !ok {
+			return "", false
+		}
+		return literal.As, true
+	case step.As != "":
+		return step.As, true
+	default:
+		return "", false
+	}
+}
+
+// mergeStep replaces base with override, except that when both resolve to
+// a literal step (directly, or through a reference), override's
+// Environment and Dependencies are merged additively with base's instead
+// of replacing them outright: an entry present in both wins for override.
+func (r *registry) mergeStep(base, override api.TestStep) api.TestStep {
+	baseLiteral, baseOK := r.literalOf(base)
+	overrideLiteral, overrideOK := r.literalOf(override)
+	if !baseOK || !overrideOK {
+		return override
+	}
+	merged := overrideLiteral
+	merged.Environment = mergeStepParameters(baseLiteral.Environment, overrideLiteral.Environment)
+	merged.Dependencies = mergeDependencies(baseLiteral.Dependencies, overrideLiteral.Dependencies)
+	return api.TestStep{LiteralTestStep: &merged, When: override.When}
+}
+
+func (r *registry) literalOf(step api.TestStep) (api.LiteralTestStep, bool) {
+	switch {
+	case step.LiteralTestStep != nil:
+		return *step.LiteralTestStep, true
+	case step.Reference != nil:
+		literal, ok := r.stepsByName[*step.Reference]
+		return literal, ok
+	default:
+		return api.LiteralTestStep{}, false
+	}
+}
+
+func mergeStepParameters(base, override []api.StepParameter) []api.StepParameter {
+	seen := make(map[string]bool, len(override))
+	merged := make([]api.StepParameter, 0, len(base)+len(override))
+	for _, param := range override {
+		seen[param.Name] = true
+		merged = append(merged, param)
+	}
+	for _, param := range base {
+		if !seen[param.Name] {
+			merged = append(merged, param)
+		}
+	}
+	return merged
+}
+
+func mergeDependencies(base, override []api.StepDependency) []api.StepDependency {
+	seen := make(map[string]bool, len(override))
+	merged := make([]api.StepDependency, 0, len(base)+len(override))
+	for _, dep := range override {
+		seen[dep.Env] = true
+		merged = append(merged, dep)
+	}
+	for _, dep := range base {
+		if !seen[dep.Env] {
+			merged = append(merged, dep)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestMergeStepListAppendsNewStep(t *testing.T) {
+	r := &registry{}
+	base := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "unit"}}}
+	overrides := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "e2e"}}}
+
+	merged := r.mergeStepList(base, overrides)
+
+	var got []string
+	for _, step := range merged {
+		got = append(got, step.LiteralTestStep.As)
+	}
+	if want := []string{"unit", "e2e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeStepList = %v, want %v", got, want)
+	}
+}
+
+func TestMergeStepListReplacesByNameMergingEnvAndDependencies(t *testing.T) {
+	r := &registry{}
+	base := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{
+		As:           "unit",
+		Environment:  []api.StepParameter{{Name: "FOO"}},
+		Dependencies: []api.StepDependency{{Name: "base", Env: "BASE_IMG"}},
+	}}}
+	overrides := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{
+		As:           "unit",
+		Commands:     "make test",
+		Environment:  []api.StepParameter{{Name: "BAR"}},
+		Dependencies: []api.StepDependency{{Name: "override", Env: "OVERRIDE_IMG"}},
+	}}}
+
+	merged := r.mergeStepList(base, overrides)
+
+	if len(merged) != 1 {
+		t.Fatalf("expected the override to replace the base step in place, got %+v", merged)
+	}
+	step := merged[0].LiteralTestStep
+	if step == nil {
+		t.Fatalf("expected a literal step, got %+v", merged[0])
+	}
+	if step.Commands != "make test" {
+		t.Errorf("expected the override's own fields to win, got Commands=%q", step.Commands)
+	}
+	wantEnv := []api.StepParameter{{Name: "BAR"}, {Name: "FOO"}}
+	if !reflect.DeepEqual(step.Environment, wantEnv) {
+		t.Errorf("Environment = %+v, want %+v (override entries first, then base entries not overridden)", step.Environment, wantEnv)
+	}
+	wantDeps := []api.StepDependency{{Name: "override", Env: "OVERRIDE_IMG"}, {Name: "base", Env: "BASE_IMG"}}
+	if !reflect.DeepEqual(step.Dependencies, wantDeps) {
+		t.Errorf("Dependencies = %+v, want %+v", step.Dependencies, wantDeps)
+	}
+}
+
+func TestMergeStepListSkipRemovesBaseStep(t *testing.T) {
+	r := &registry{}
+	base := []api.TestStep{
+		{LiteralTestStep: &api.LiteralTestStep{As: "unit"}},
+		{LiteralTestStep: &api.LiteralTestStep{As: "e2e"}},
+	}
+	overrides := []api.TestStep{{As: "e2e", Skip: true}}
+
+	merged := r.mergeStepList(base, overrides)
+
+	var got []string
+	for _, step := range merged {
+		got = append(got, step.LiteralTestStep.As)
+	}
+	if want := []string{"unit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeStepList = %v, want %v", got, want)
+	}
+}
+
+func TestMergeStepListAppendsOverrideThatCannotBeNamed(t *testing.T) {
+	r := &registry{stepsByName: ReferenceByName{}}
+	base := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "unit"}}}
+	overrides := []api.TestStep{{Reference: strPtr("missing")}}
+
+	merged := r.mergeStepList(base, overrides)
+
+	if len(merged) != 2 {
+		t.Fatalf("expected the unresolvable override to be appended rather than dropped, got %+v", merged)
+	}
+	if merged[1].Reference == nil || *merged[1].Reference != "missing" {
+		t.Errorf("expected the unresolvable override to be appended unchanged, got %+v", merged[1])
+	}
+}
@@ -8,7 +8,45 @@ import (
 )
 
 type Resolver interface {
-	Resolve(config api.MultiStageTestConfiguration) (api.MultiStageTestConfigurationLiteral, error)
+	Resolve(config api.MultiStageTestConfiguration, ctx ResolveContext) (api.MultiStageTestConfigurationLiteral, error)
+	// Validate performs a pre-flight pass over the registry contents,
+	// independent of any particular config, and reports dangling
+	// references and chain cycles before they can cause a lazy failure
+	// (or, for cycles, an infinite loop) during Resolve.
+	Validate() error
+}
+
+// ResolveContext carries the information a step's `when:` expression may
+// reference while Resolve flattens a config's Pre/Test/Post into literal
+// steps. Variables takes precedence below the reserved names `profile`,
+// `org`, `repo`, and `branch`, which are always set from the other fields.
+type ResolveContext struct {
+	ClusterProfile api.ClusterProfile
+	Org            string
+	Repo           string
+	Branch         string
+	Variables      map[string]string
+
+	// NoHooks disables hook application entirely, as an escape hatch for
+	// callers that want the bare workflow/chain/reference resolution.
+	NoHooks bool
+	// Trace, if non-nil, is populated with a record of which hooks fired
+	// on which step.
+	Trace *ResolveTrace
+}
+
+// vars flattens a ResolveContext into the variable lookup used to evaluate
+// `when:` expressions.
+func (c ResolveContext) vars() map[string]string {
+	vars := make(map[string]string, len(c.Variables)+4)
+	for k, v := range c.Variables {
+		vars[k] = v
+	}
+	vars["profile"] = string(c.ClusterProfile)
+	vars["org"] = c.Org
+	vars["repo"] = c.Repo
+	vars["branch"] = c.Branch
+	return vars
 }
 
 type ReferenceByName map[string]api.LiteralTestStep
@@ -22,17 +60,25 @@ type registry struct {
 	stepsByName     ReferenceByName
 	chainsByName    ChainByName
 	workflowsByName WorkflowByName
+	hooksByName     HooksByName
 }
 
-func NewResolver(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName) Resolver {
+func NewResolver(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName, hooksByName HooksByName) Resolver {
 	return &registry{
 		stepsByName:     stepsByName,
 		chainsByName:    chainsByName,
 		workflowsByName: workflowsByName,
+		hooksByName:     hooksByName,
 	}
 }
 
-func (r *registry) Resolve(config api.MultiStageTestConfiguration) (api.MultiStageTestConfigurationLiteral, error) {
+// Validate implements Resolver.Validate by checking the registry this
+// resolver was constructed with.
+func (r *registry) Validate() error {
+	return ValidateRegistry(r.stepsByName, r.chainsByName, r.workflowsByName, r.hooksByName)
+}
+
+func (r *registry) Resolve(config api.MultiStageTestConfiguration, ctx ResolveContext) (api.MultiStageTestConfigurationLiteral, error) {
 	var resolveErrors []error
 	if config.Workflow != nil {
 		workflow, ok := r.workflowsByName[*config.Workflow]
@@ -44,52 +90,79 @@ func (r *registry) Resolve(config api.MultiStageTestConfiguration) (api.MultiSta
 		if config.ClusterProfile == "" {
 			config.ClusterProfile = workflow.ClusterProfile
 		}
-		if config.Pre == nil {
-			config.Pre = workflow.Pre
-		}
-		if config.Test == nil {
-			config.Test = workflow.Test
+		if config.WorkflowMode == api.WorkflowModeMerge {
+			config.Pre = r.mergeStepList(workflow.Pre, config.Pre)
+			config.Test = r.mergeStepList(workflow.Test, config.Test)
+			config.Post = r.mergeStepList(workflow.Post, config.Post)
+		} else {
+			if config.Pre == nil {
+				config.Pre = workflow.Pre
+			}
+			if config.Test == nil {
+				config.Test = workflow.Test
+			}
+			if config.Post == nil {
+				config.Post = workflow.Post
+			}
 		}
-		if config.Post == nil {
-			config.Post = workflow.Post
+		if config.Hooks == nil {
+			config.Hooks = workflow.Hooks
 		}
 	}
 	expandedFlow := api.MultiStageTestConfigurationLiteral{
 		ClusterProfile: config.ClusterProfile,
 	}
-	pre, errs := r.process(config.Pre)
+	vars := ctx.vars()
+	pre, errs := r.process(config.Pre, vars)
 	expandedFlow.Pre = append(expandedFlow.Pre, pre...)
 	resolveErrors = append(resolveErrors, errs...)
 
-	test, errs := r.process(config.Test)
+	test, errs := r.process(config.Test, vars)
 	expandedFlow.Test = append(expandedFlow.Test, test...)
 	resolveErrors = append(resolveErrors, errs...)
 
-	post, errs := r.process(config.Post)
+	post, errs := r.process(config.Post, vars)
 	expandedFlow.Post = append(expandedFlow.Post, post...)
 	resolveErrors = append(resolveErrors, errs...)
 
+	if !ctx.NoHooks {
+		var hookErrs []error
+		expandedFlow, hookErrs = r.applyHooks(expandedFlow, config.Hooks, vars, ctx.Trace)
+		resolveErrors = append(resolveErrors, hookErrs...)
+	}
+
 	if resolveErrors != nil {
 		return api.MultiStageTestConfigurationLiteral{}, errors.NewAggregate(resolveErrors)
 	}
+	expandedFlow.Signature = flowSignature(expandedFlow.ClusterProfile, expandedFlow.Pre, expandedFlow.Test, expandedFlow.Post)
 	return expandedFlow, nil
 }
 
-func (r *registry) process(steps []api.TestStep) (literalSteps []api.LiteralTestStep, errs []error) {
+func (r *registry) process(steps []api.TestStep, vars map[string]string) (literalSteps []api.LiteralTestStep, errs []error) {
 	// unroll chains
 	var unrolledSteps []api.TestStep
-	unrolledSteps, err := r.unrollChains(steps)
+	unrolledSteps, err := r.unrollChains(steps, vars)
 	if err != nil {
 		errs = append(errs, err...)
 	}
+	// expand matrix (`foreach`) steps
+	flattenedSteps, ferrs := r.expandForeach(unrolledSteps, vars)
+	errs = append(errs, ferrs...)
 	// process steps
-	for _, external := range unrolledSteps {
+	for _, external := range flattenedSteps {
+		if active, err := evalWhen(external.When, vars); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !active {
+			continue
+		}
 		var step api.LiteralTestStep
 		if external.Reference != nil {
 			var err error
 			step, err = r.dereference(external)
 			if err != nil {
 				errs = append(errs, err)
+				continue
 			}
 		} else if external.LiteralTestStep != nil {
 			step = *external.LiteralTestStep
@@ -97,6 +170,18 @@ func (r *registry) process(steps []api.TestStep) (literalSteps []api.LiteralTest
 			errs = append(errs, fmt.Errorf("encountered TestStep where both `Reference` and `LiteralTestStep` are nil"))
 			continue
 		}
+		if active, err := evalWhen(step.When, vars); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !active {
+			continue
+		}
+		signature, err := stepSignature(step)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		step.Signature = signature
 		literalSteps = append(literalSteps, step)
 	}
 	if err := checkForDuplicates(literalSteps); err != nil {
@@ -105,15 +190,39 @@ func (r *registry) process(steps []api.TestStep) (literalSteps []api.LiteralTest
 	return
 }
 
-func (r *registry) unrollChains(input []api.TestStep) (unrolledSteps []api.TestStep, errs []error) {
+// evalWhen evaluates a step's `when:` expression, if it has one. An empty
+// expression is always active.
+func evalWhen(expr string, vars map[string]string) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	cond, err := ParseStepCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	return cond.Eval(vars)
+}
+
+// unrollChains replaces every Chain-referencing step in input with that
+// chain's own steps, recursing into nested chains. A chain-referencing
+// step's own When is evaluated here, before it is spliced in: unlike
+// Reference/LiteralTestStep steps, a chain step's contents are discarded
+// by the splice, so there is nowhere else to honor it.
+func (r *registry) unrollChains(input []api.TestStep, vars map[string]string) (unrolledSteps []api.TestStep, errs []error) {
 	for _, step := range input {
 		if step.Chain != nil {
+			if active, err := evalWhen(step.When, vars); err != nil {
+				errs = append(errs, err)
+				continue
+			} else if !active {
+				continue
+			}
 			chain, ok := r.chainsByName[*step.Chain]
 			if !ok {
 				return []api.TestStep{}, []error{fmt.Errorf("unknown step chain: %s", *step.Chain)}
 			}
 			// handle nested chains
-			chain, err := r.unrollChains(chain)
+			chain, err := r.unrollChains(chain, vars)
 			if err != nil {
 				errs = append(errs, err...)
 			}
@@ -146,7 +255,7 @@ func checkForDuplicates(input []api.LiteralTestStep) (errs []error) {
 }
 
 // ResolveConfig uses a resolver to resolve an entire ci-operator config
-func ResolveConfig(resolver Resolver, config api.ReleaseBuildConfiguration) (api.ReleaseBuildConfiguration, error) {
+func ResolveConfig(resolver Resolver, config api.ReleaseBuildConfiguration, ctx ResolveContext) (api.ReleaseBuildConfiguration, error) {
 	var resolvedTests []api.TestStepConfiguration
 	for _, step := range config.Tests {
 		// no changes if step is not multi-stage
@@ -154,7 +263,7 @@ func ResolveConfig(resolver Resolver, config api.ReleaseBuildConfiguration) (api
 			resolvedTests = append(resolvedTests, step)
 			continue
 		}
-		resolvedConfig, err := resolver.Resolve(*step.MultiStageTestConfiguration)
+		resolvedConfig, err := resolver.Resolve(*step.MultiStageTestConfiguration, ctx)
 		if err != nil {
 			return api.ReleaseBuildConfiguration{}, fmt.Errorf("Failed resolve MultiStageTestConfiguration: %v", err)
 		}
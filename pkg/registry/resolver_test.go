@@ -0,0 +1,34 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestUnrollChainsHonorsWhenOnTheChainStep(t *testing.T) {
+	r := &registry{
+		chainsByName: ChainByName{
+			"e2e-chain": {{LiteralTestStep: &api.LiteralTestStep{As: "e2e"}}},
+		},
+	}
+	vars := map[string]string{"profile": "gcp"}
+
+	skipped, errs := r.unrollChains([]api.TestStep{{Chain: strPtr("e2e-chain"), When: `profile == "aws"`}}, vars)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("expected the chain to be skipped when its When is false, got %+v", skipped)
+	}
+
+	included, errs := r.unrollChains([]api.TestStep{{Chain: strPtr("e2e-chain"), When: `profile == "gcp"`}}, vars)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := []api.TestStep{{LiteralTestStep: &api.LiteralTestStep{As: "e2e"}}}
+	if !reflect.DeepEqual(included, want) {
+		t.Errorf("expected the chain to be spliced in when its When is true, got %+v, want %+v", included, want)
+	}
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+// stepSignaturePayload holds exactly the fields of a LiteralTestStep that
+// affect what it actually does; As, Hooks, When, and the Signature itself
+// are cosmetic or derived and are deliberately left out so that renaming a
+// step or changing how it's selected doesn't perturb its signature. Map
+// fields (inside Resources) are encoded with sorted keys by
+// encoding/json, so field reordering in YAML never changes the result;
+// Environment, Credentials, and Dependencies are slices, so stepSignature
+// sorts copies of them by their stable key before they reach here, for the
+// same reason.
+type stepSignaturePayload struct {
+	Commands     string                       `json:"commands,omitempty"`
+	From         string                       `json:"from,omitempty"`
+	FromImage    *api.ImageStreamTagReference `json:"from_image,omitempty"`
+	Resources    api.ResourceRequirements     `json:"resources,omitempty"`
+	Environment  []api.StepParameter          `json:"env,omitempty"`
+	Credentials  []api.CredentialReference    `json:"credentials,omitempty"`
+	Dependencies []api.StepDependency         `json:"dependencies,omitempty"`
+}
+
+// stepSignature computes a stable, content-addressable signature for a
+// resolved step, hashing the canonical JSON encoding of its non-cosmetic
+// fields with SHA-256. Environment, Credentials, and Dependencies are
+// sorted by their stable key first, on copies of the step's slices, so two
+// steps differing only in the declaration order of these fields (e.g. one
+// resolved through a merge override, the other not) hash identically.
+func stepSignature(step api.LiteralTestStep) (string, error) {
+	raw, err := json.Marshal(stepSignaturePayload{
+		Commands:     step.Commands,
+		From:         step.From,
+		FromImage:    step.FromImage,
+		Resources:    step.Resources,
+		Environment:  sortedStepParameters(step.Environment),
+		Credentials:  sortedCredentials(step.Credentials),
+		Dependencies: sortedDependencies(step.Dependencies),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize step %q for signing: %w", step.As, err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedStepParameters(params []api.StepParameter) []api.StepParameter {
+	sorted := append([]api.StepParameter{}, params...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+func sortedCredentials(credentials []api.CredentialReference) []api.CredentialReference {
+	sorted := append([]api.CredentialReference{}, credentials...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.MountPath < b.MountPath
+	})
+	return sorted
+}
+
+func sortedDependencies(dependencies []api.StepDependency) []api.StepDependency {
+	sorted := append([]api.StepDependency{}, dependencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Env < sorted[j].Env })
+	return sorted
+}
+
+// flowSignature computes a MultiStageTestConfigurationLiteral's aggregate
+// signature as the SHA-256, in hex, of its ClusterProfile followed by the
+// concatenation of its steps' own signatures, in order.
+func flowSignature(profile api.ClusterProfile, sections ...[]api.LiteralTestStep) string {
+	h := sha256.New()
+	h.Write([]byte(profile))
+	for _, steps := range sections {
+		for _, step := range steps {
+			h.Write([]byte(step.Signature))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestStepSignatureIgnoresCosmeticFields(t *testing.T) {
+	a := api.LiteralTestStep{As: "unit-test", Commands: "make test"}
+	b := api.LiteralTestStep{As: "different-name", Commands: "make test", When: "profile == \"aws\""}
+
+	sigA, err := stepSignature(a)
+	if err != nil {
+		t.Fatalf("stepSignature(a): %v", err)
+	}
+	sigB, err := stepSignature(b)
+	if err != nil {
+		t.Fatalf("stepSignature(b): %v", err)
+	}
+	if sigA != sigB {
+		t.Errorf("expected signatures to match despite differing As/When, got %s and %s", sigA, sigB)
+	}
+}
+
+func TestStepSignatureDetectsRealChanges(t *testing.T) {
+	base := api.LiteralTestStep{As: "unit-test", Commands: "make test"}
+	changed := api.LiteralTestStep{As: "unit-test", Commands: "make test TARGET=other"}
+
+	sigBase, err := stepSignature(base)
+	if err != nil {
+		t.Fatalf("stepSignature(base): %v", err)
+	}
+	sigChanged, err := stepSignature(changed)
+	if err != nil {
+		t.Fatalf("stepSignature(changed): %v", err)
+	}
+	if sigBase == sigChanged {
+		t.Errorf("expected signatures to differ once Commands changes, both were %s", sigBase)
+	}
+}
+
+func TestStepSignatureIsOrderIndependentForMaps(t *testing.T) {
+	// Build the same resource requests in two different insertion orders,
+	// simulating the field reordering that YAML parsing can introduce.
+	first := map[string]string{}
+	first["cpu"] = "100m"
+	first["memory"] = "200Mi"
+
+	second := map[string]string{}
+	second["memory"] = "200Mi"
+	second["cpu"] = "100m"
+
+	stepA := api.LiteralTestStep{As: "unit-test", Resources: api.ResourceRequirements{Requests: first}}
+	stepB := api.LiteralTestStep{As: "unit-test", Resources: api.ResourceRequirements{Requests: second}}
+
+	sigA, err := stepSignature(stepA)
+	if err != nil {
+		t.Fatalf("stepSignature(stepA): %v", err)
+	}
+	sigB, err := stepSignature(stepB)
+	if err != nil {
+		t.Fatalf("stepSignature(stepB): %v", err)
+	}
+	if sigA != sigB {
+		t.Errorf("expected map key reordering to leave the signature unchanged, got %s and %s", sigA, sigB)
+	}
+}
+
+func TestStepSignatureIsOrderIndependentForSlices(t *testing.T) {
+	// Build the same Environment, Credentials, and Dependencies in two
+	// different orders, simulating both a plain YAML field reordering and
+	// the reordering mergeStepParameters/mergeDependencies produce when a
+	// workflow override is merged in.
+	env1 := []api.StepParameter{{Name: "A"}, {Name: "B"}}
+	env2 := []api.StepParameter{{Name: "B"}, {Name: "A"}}
+
+	creds1 := []api.CredentialReference{{Namespace: "ns", Name: "a"}, {Namespace: "ns", Name: "b"}}
+	creds2 := []api.CredentialReference{{Namespace: "ns", Name: "b"}, {Namespace: "ns", Name: "a"}}
+
+	deps1 := []api.StepDependency{{Env: "A_IMAGE", Name: "a"}, {Env: "B_IMAGE", Name: "b"}}
+	deps2 := []api.StepDependency{{Env: "B_IMAGE", Name: "b"}, {Env: "A_IMAGE", Name: "a"}}
+
+	stepA := api.LiteralTestStep{As: "unit-test", Environment: env1, Credentials: creds1, Dependencies: deps1}
+	stepB := api.LiteralTestStep{As: "unit-test", Environment: env2, Credentials: creds2, Dependencies: deps2}
+
+	sigA, err := stepSignature(stepA)
+	if err != nil {
+		t.Fatalf("stepSignature(stepA): %v", err)
+	}
+	sigB, err := stepSignature(stepB)
+	if err != nil {
+		t.Fatalf("stepSignature(stepB): %v", err)
+	}
+	if sigA != sigB {
+		t.Errorf("expected slice reordering to leave the signature unchanged, got %s and %s", sigA, sigB)
+	}
+
+	// stepSignature must not mutate the step it was handed.
+	if stepA.Environment[0].Name != "A" || stepA.Credentials[0].Name != "a" || stepA.Dependencies[0].Env != "A_IMAGE" {
+		t.Errorf("stepSignature mutated its caller's slices: %+v", stepA)
+	}
+}
+
+func TestFlowSignatureIsOrderSensitiveAcrossSteps(t *testing.T) {
+	one := api.LiteralTestStep{As: "one", Signature: "11"}
+	two := api.LiteralTestStep{As: "two", Signature: "22"}
+
+	forward := flowSignature(api.ClusterProfile("aws"), []api.LiteralTestStep{one, two})
+	backward := flowSignature(api.ClusterProfile("aws"), []api.LiteralTestStep{two, one})
+
+	if forward == backward {
+		t.Errorf("expected reordering steps to change the flow signature")
+	}
+}
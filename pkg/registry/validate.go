@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/ci-tools/pkg/api"
+	"k8s.io/apimachinery/pkg/util/errors"
+)
+
+// colour is used to mark nodes during the chain-cycle DFS: white nodes have
+// not been visited, grey nodes are on the current DFS stack, and black nodes
+// have been fully explored.
+type colour int
+
+const (
+	white colour = iota
+	grey
+	black
+)
+
+// ValidateRegistry walks every workflow, chain, reference, and hook
+// selector in the given registry contents and returns an aggregated error
+// describing any references to unknown steps, chains, or hooks, chain
+// cycles, and duplicate `as` names within a resolved workflow. It is meant
+// to be run once, at load time, so that a corrupt registry is rejected
+// before it can cause `unrollChains` to recurse forever or `Resolve` to
+// fail deep into a job.
+func ValidateRegistry(stepsByName ReferenceByName, chainsByName ChainByName, workflowsByName WorkflowByName, hooksByName HooksByName) error {
+	var validationErrors []error
+
+	validationErrors = append(validationErrors, detectChainCycles(chainsByName)...)
+
+	for name, step := range stepsByName {
+		validationErrors = append(validationErrors, validateHookSelectors(fmt.Sprintf("step %q", name), step.Hooks, hooksByName)...)
+	}
+
+	for name, chain := range chainsByName {
+		validationErrors = append(validationErrors, validateStepReferences(fmt.Sprintf("chain %q", name), chain, stepsByName, chainsByName)...)
+		validationErrors = append(validationErrors, validateLiteralHookSelectors(fmt.Sprintf("chain %q", name), chain, chainsByName, hooksByName)...)
+	}
+
+	for name, workflow := range workflowsByName {
+		validationErrors = append(validationErrors, validateHookSelectors(fmt.Sprintf("workflow %q hooks", name), workflow.Hooks, hooksByName)...)
+		validationErrors = append(validationErrors, validateWorkflow(name, workflow, stepsByName, chainsByName, hooksByName)...)
+	}
+
+	if validationErrors != nil {
+		return errors.NewAggregate(validationErrors)
+	}
+	return nil
+}
+
+// detectChainCycles runs a colour-marking DFS over chainsByName: white nodes
+// are unvisited, grey nodes are on the current stack, and black nodes are
+// done. Re-encountering a grey node means we have found a cycle, which is
+// reported as the path that leads back to it (e.g. "a -> b -> a").
+func detectChainCycles(chainsByName ChainByName) []error {
+	colours := make(map[string]colour, len(chainsByName))
+	var errs []error
+
+	var visit func(name string, path []string)
+	visit = func(name string, path []string) {
+		colours[name] = grey
+		path = append(path, name)
+		for _, step := range chainsByName[name] {
+			if step.Chain == nil {
+				continue
+			}
+			next := *step.Chain
+			if _, ok := chainsByName[next]; !ok {
+				// unknown chains are reported by validateStepReferences
+				continue
+			}
+			switch colours[next] {
+			case white:
+				visit(next, path)
+			case grey:
+				errs = append(errs, fmt.Errorf("chain cycle detected: %s", strings.Join(append(path, next), " -> ")))
+			case black:
+				// already fully explored from elsewhere, no new cycle here
+			}
+		}
+		colours[name] = black
+	}
+
+	for name := range chainsByName {
+		if colours[name] == white {
+			visit(name, nil)
+		}
+	}
+	return errs
+}
+
+// validateStepReferences checks that every reference and chain named by
+// steps exists, recursing into nested chains. context is prepended to
+// errors to identify where the bad reference was found. Chains already
+// being visited on this path are skipped instead of re-entered, since a
+// cycle through them is already reported by detectChainCycles.
+func validateStepReferences(context string, steps []api.TestStep, stepsByName ReferenceByName, chainsByName ChainByName) []error {
+	return validateStepReferencesVisiting(context, steps, stepsByName, chainsByName, map[string]bool{})
+}
+
+func validateStepReferencesVisiting(context string, steps []api.TestStep, stepsByName ReferenceByName, chainsByName ChainByName, visiting map[string]bool) (errs []error) {
+	for _, step := range steps {
+		switch {
+		case step.Reference != nil:
+			// A matrix token can only be resolved once expandForeach has a
+			// concrete value for it, so a reference naming one isn't
+			// checkable here; expandForeach/dereference will still catch
+			// a name that resolves to nothing at Resolve time.
+			if matrixToken.MatchString(*step.Reference) {
+				continue
+			}
+			if _, ok := stepsByName[*step.Reference]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown step reference: %s", context, *step.Reference))
+			}
+		case step.Chain != nil:
+			if matrixToken.MatchString(*step.Chain) {
+				continue
+			}
+			chain, ok := chainsByName[*step.Chain]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown step chain: %s", context, *step.Chain))
+				continue
+			}
+			if visiting[*step.Chain] {
+				continue
+			}
+			visiting[*step.Chain] = true
+			errs = append(errs, validateStepReferencesVisiting(fmt.Sprintf("%s -> chain %q", context, *step.Chain), chain, stepsByName, chainsByName, visiting)...)
+			delete(visiting, *step.Chain)
+		case step.LiteralTestStep != nil:
+			// fully self-contained, nothing to resolve
+		case step.Foreach != nil:
+			errs = append(errs, validateStepReferencesVisiting(fmt.Sprintf("%s -> foreach", context), step.Foreach.Steps, stepsByName, chainsByName, visiting)...)
+		default:
+			errs = append(errs, fmt.Errorf("%s: step has neither a reference, a chain, nor a literal test step", context))
+		}
+	}
+	return errs
+}
+
+// validateHookSelectors checks that every HookSelector naming a hook
+// directly (as opposed to matching one or more by label) names one that is
+// actually registered.
+func validateHookSelectors(context string, selectors []api.HookSelector, hooksByName HooksByName) (errs []error) {
+	for _, selector := range selectors {
+		if selector.Name == "" {
+			continue
+		}
+		if _, ok := hooksByName[selector.Name]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown hook: %s", context, selector.Name))
+		}
+	}
+	return errs
+}
+
+// validateLiteralHookSelectors checks the Hooks selectors of every literal
+// step reachable from steps, once chains have been unrolled. A step that is
+// itself a reference is skipped here: its Hooks are checked once, directly,
+// against the stepsByName entry it names.
+func validateLiteralHookSelectors(context string, steps []api.TestStep, chainsByName ChainByName, hooksByName HooksByName) (errs []error) {
+	for _, step := range safeUnrollChains(steps, chainsByName, map[string]bool{}) {
+		if step.LiteralTestStep != nil {
+			errs = append(errs, validateHookSelectors(context, step.LiteralTestStep.Hooks, hooksByName)...)
+		}
+		if step.Foreach != nil {
+			errs = append(errs, validateLiteralHookSelectors(context, step.Foreach.Steps, chainsByName, hooksByName)...)
+		}
+	}
+	return errs
+}
+
+// validateWorkflow validates the dangling-reference rules for a single
+// workflow's Pre/Test/Post sections and also checks each section for
+// duplicate `as` names once its chains are unrolled. A chain already on
+// the current path is not re-entered, so a section reaching a chain cycle
+// is checked on its partial unrolling.
+func validateWorkflow(name string, workflow api.MultiStageTestConfiguration, stepsByName ReferenceByName, chainsByName ChainByName, hooksByName HooksByName) (errs []error) {
+	for _, section := range []struct {
+		name  string
+		steps []api.TestStep
+	}{
+		{"pre", workflow.Pre},
+		{"test", workflow.Test},
+		{"post", workflow.Post},
+	} {
+		context := fmt.Sprintf("workflow %q %s", name, section.name)
+		errs = append(errs, validateStepReferences(context, section.steps, stepsByName, chainsByName)...)
+		errs = append(errs, validateLiteralHookSelectors(context, section.steps, chainsByName, hooksByName)...)
+
+		var literalSteps []api.LiteralTestStep
+		for _, step := range safeUnrollChains(section.steps, chainsByName, map[string]bool{}) {
+			switch {
+			case step.Reference != nil:
+				if literal, ok := stepsByName[*step.Reference]; ok {
+					literalSteps = append(literalSteps, literal)
+				}
+			case step.LiteralTestStep != nil:
+				literalSteps = append(literalSteps, *step.LiteralTestStep)
+			}
+		}
+		for _, err := range checkForDuplicates(literalSteps) {
+			errs = append(errs, fmt.Errorf("%s: %w", context, err))
+		}
+	}
+	return errs
+}
+
+// safeUnrollChains is like unrollChains but never recurses into a chain that
+// is already being expanded on the current path, so it terminates even when
+// the registry contains a cycle that detectChainCycles has already flagged.
+func safeUnrollChains(steps []api.TestStep, chainsByName ChainByName, visiting map[string]bool) []api.TestStep {
+	var out []api.TestStep
+	for _, step := range steps {
+		if step.Chain == nil {
+			out = append(out, step)
+			continue
+		}
+		chain, ok := chainsByName[*step.Chain]
+		if !ok || visiting[*step.Chain] {
+			continue
+		}
+		visiting[*step.Chain] = true
+		out = append(out, safeUnrollChains(chain, chainsByName, visiting)...)
+		delete(visiting, *step.Chain)
+	}
+	return out
+}
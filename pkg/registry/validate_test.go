@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/ci-tools/pkg/api"
+)
+
+func TestValidateRegistryRejectsUnknownHookName(t *testing.T) {
+	stepsByName := ReferenceByName{
+		"unit-test": {As: "unit-test"},
+	}
+	workflowsByName := WorkflowByName{
+		"generic": {
+			Hooks: []api.HookSelector{{Name: "typo-d-hook"}},
+			Test:  []api.TestStep{{Reference: strPtr("unit-test")}},
+		},
+	}
+
+	err := ValidateRegistry(stepsByName, ChainByName{}, workflowsByName, HooksByName{})
+	if err == nil {
+		t.Fatal("expected an error for a workflow hook selector naming an unregistered hook")
+	}
+	if !strings.Contains(err.Error(), "typo-d-hook") {
+		t.Errorf("expected error to name the unknown hook, got: %v", err)
+	}
+}
+
+func TestValidateRegistryAcceptsKnownHookName(t *testing.T) {
+	stepsByName := ReferenceByName{
+		"unit-test": {As: "unit-test"},
+	}
+	workflowsByName := WorkflowByName{
+		"generic": {
+			Hooks: []api.HookSelector{{Name: "must-gather"}},
+			Test:  []api.TestStep{{Reference: strPtr("unit-test")}},
+		},
+	}
+	hooksByName := HooksByName{"must-gather": {}}
+
+	if err := ValidateRegistry(stepsByName, ChainByName{}, workflowsByName, hooksByName); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestDetectChainCyclesReportsCycle(t *testing.T) {
+	chainsByName := ChainByName{
+		"a": {{Chain: strPtr("b")}},
+		"b": {{Chain: strPtr("c")}},
+		"c": {{Chain: strPtr("a")}},
+	}
+
+	errs := detectChainCycles(chainsByName)
+	if len(errs) == 0 {
+		t.Fatal("expected a cycle to be reported")
+	}
+}
+
+func TestDetectChainCyclesAcceptsDiamond(t *testing.T) {
+	// "a" reaches "d" through both "b" and "c"; that's not a cycle, and the
+	// DFS must not mistake revisiting an already-fully-explored node for one.
+	chainsByName := ChainByName{
+		"a": {{Chain: strPtr("b")}, {Chain: strPtr("c")}},
+		"b": {{Chain: strPtr("d")}},
+		"c": {{Chain: strPtr("d")}},
+		"d": {},
+	}
+
+	if errs := detectChainCycles(chainsByName); len(errs) != 0 {
+		t.Errorf("expected no cycle in a diamond-shaped graph, got: %v", errs)
+	}
+}
+
+func TestValidateRegistryDetectsDanglingReferences(t *testing.T) {
+	workflowsByName := WorkflowByName{
+		"generic": {
+			Test: []api.TestStep{
+				{Reference: strPtr("does-not-exist")},
+				{Chain: strPtr("also-missing")},
+			},
+		},
+	}
+
+	err := ValidateRegistry(ReferenceByName{}, ChainByName{}, workflowsByName, HooksByName{})
+	if err == nil {
+		t.Fatal("expected dangling reference and chain to be reported")
+	}
+	if !strings.Contains(err.Error(), "does-not-exist") || !strings.Contains(err.Error(), "also-missing") {
+		t.Errorf("expected error to name both dangling references, got: %v", err)
+	}
+}
+
+func TestValidateRegistryDetectsDuplicateStepNames(t *testing.T) {
+	stepsByName := ReferenceByName{
+		"a": {As: "shared"},
+	}
+	workflowsByName := WorkflowByName{
+		"generic": {
+			Test: []api.TestStep{
+				{Reference: strPtr("a")},
+				{LiteralTestStep: &api.LiteralTestStep{As: "shared"}},
+			},
+		},
+	}
+
+	err := ValidateRegistry(stepsByName, ChainByName{}, workflowsByName, HooksByName{})
+	if err == nil {
+		t.Fatal("expected duplicate `as` names within a workflow section to be reported")
+	}
+}
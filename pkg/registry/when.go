@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// StepCondition is a parsed `when:` expression. It supports equality
+// (==, !=), membership with glob matching (`in [...]`), the logical
+// operators &&, ||, and !, and parentheses for grouping.
+type StepCondition struct {
+	root whenNode
+}
+
+// ParseStepCondition parses a `when:` expression into a StepCondition that
+// can be evaluated repeatedly against different variables.
+func ParseStepCondition(expr string) (*StepCondition, error) {
+	p := &whenParser{tokens: tokenizeWhen(expr)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid when expression %q: %w", expr, err)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("invalid when expression %q: unexpected token %q", expr, p.tokens[p.pos].text)
+	}
+	return &StepCondition{root: node}, nil
+}
+
+// Eval evaluates the condition against the given variables. Identifiers in
+// the expression that are not present in vars evaluate to the empty
+// string, so they equal only the literal "" and match only glob patterns
+// that accept an empty string (such as "*").
+func (c *StepCondition) Eval(vars map[string]string) (bool, error) {
+	return c.root.eval(vars)
+}
+
+type whenNode interface {
+	eval(vars map[string]string) (bool, error)
+}
+
+type notNode struct{ operand whenNode }
+
+func (n notNode) eval(vars map[string]string) (bool, error) {
+	v, err := n.operand.eval(vars)
+	return !v, err
+}
+
+// boolOp short-circuits, same as Go's && and ||.
+type boolOp struct {
+	left, right whenNode
+	and         bool
+}
+
+func (b boolOp) eval(vars map[string]string) (bool, error) {
+	left, err := b.left.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	if left != b.and {
+		// && and a false left, or || and a true left: short-circuit
+		return left, nil
+	}
+	return b.right.eval(vars)
+}
+
+// operand is either a string literal or an identifier to be looked up in
+// the variables passed to Eval.
+type operand struct {
+	literal bool
+	value   string
+}
+
+func (o operand) resolve(vars map[string]string) string {
+	if o.literal {
+		return o.value
+	}
+	return vars[o.value]
+}
+
+type eqNode struct {
+	left, right operand
+	negate      bool
+}
+
+func (n eqNode) eval(vars map[string]string) (bool, error) {
+	eq := n.left.resolve(vars) == n.right.resolve(vars)
+	if n.negate {
+		return !eq, nil
+	}
+	return eq, nil
+}
+
+// inNode implements `left in [patterns...]`, matching each pattern against
+// the resolved value of left as a shell glob.
+type inNode struct {
+	left     operand
+	patterns []operand
+}
+
+func (n inNode) eval(vars map[string]string) (bool, error) {
+	value := n.left.resolve(vars)
+	for _, p := range n.patterns {
+		pattern := p.resolve(vars)
+		matched, err := filepath.Match(pattern, value)
+		if err != nil {
+			return false, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
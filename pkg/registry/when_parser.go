@@ -0,0 +1,252 @@
+package registry
+
+import "fmt"
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokEq
+	tokNeq
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenizeWhen lexes a `when:` expression. Unrecognized bytes (e.g. stray
+// punctuation) are skipped rather than causing a lexer error; the parser
+// rejects the result only where skipping them leaves a malformed token
+// stream, so a stray byte between otherwise well-formed tokens is ignored.
+func tokenizeWhen(expr string) []token {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]"})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ","})
+			i++
+		case c == '!' && i+1 < len(expr) && expr[i+1] == '=':
+			tokens = append(tokens, token{tokNeq, "!="})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, token{tokNot, "!"})
+			i++
+		case c == '=' && i+1 < len(expr) && expr[i+1] == '=':
+			tokens = append(tokens, token{tokEq, "=="})
+			i += 2
+		case c == '&' && i+1 < len(expr) && expr[i+1] == '&':
+			tokens = append(tokens, token{tokAnd, "&&"})
+			i += 2
+		case c == '|' && i+1 < len(expr) && expr[i+1] == '|':
+			tokens = append(tokens, token{tokOr, "||"})
+			i += 2
+		case c == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' {
+				j++
+			}
+			tokens = append(tokens, token{tokString, expr[i+1 : j]})
+			i = j + 1
+		default:
+			j := i
+			for j < len(expr) && isWhenIdentByte(expr[j]) {
+				j++
+			}
+			if j == i {
+				i++
+				continue
+			}
+			word := expr[i:j]
+			if word == "in" {
+				tokens = append(tokens, token{tokIn, word})
+			} else {
+				tokens = append(tokens, token{tokIdent, word})
+			}
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isWhenIdentByte(b byte) bool {
+	return b == '.' || b == '_' || b == '-' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// whenParser is a hand-written recursive descent parser for the grammar:
+//
+//	expr       = or
+//	or         = and ("||" and)*
+//	and        = unary ("&&" unary)*
+//	unary      = "!" unary | primary
+//	primary    = "(" or ")" | comparison
+//	comparison = operand (("==" | "!=") operand | "in" "[" [operand ("," operand)* [","]] "]")
+//	operand    = ident | string
+type whenParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *whenParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *whenParser) parseOr() (whenNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = boolOp{left: left, right: right, and: false}
+	}
+}
+
+func (p *whenParser) parseAnd() (whenNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokAnd {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = boolOp{left: left, right: right, and: true}
+	}
+}
+
+func (p *whenParser) parseUnary() (whenNode, error) {
+	if tok, ok := p.peek(); ok && tok.kind == tokNot {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *whenParser) parsePrimary() (whenNode, error) {
+	if tok, ok := p.peek(); ok && tok.kind == tokLParen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.peek(); !ok || tok.kind != tokRParen {
+			return nil, fmt.Errorf("expected closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whenParser) parseOperand() (operand, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return operand{}, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+	switch tok.kind {
+	case tokString:
+		return operand{literal: true, value: tok.text}, nil
+	case tokIdent:
+		return operand{literal: false, value: tok.text}, nil
+	default:
+		return operand{}, fmt.Errorf("unexpected token %q, expected a value", tok.text)
+	}
+}
+
+func (p *whenParser) parseComparison() (whenNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression, expected a comparison")
+	}
+	switch tok.kind {
+	case tokEq, tokNeq:
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return eqNode{left: left, right: right, negate: tok.kind == tokNeq}, nil
+	case tokIn:
+		p.pos++
+		if tok, ok := p.peek(); !ok || tok.kind != tokLBracket {
+			return nil, fmt.Errorf("expected '[' after 'in'")
+		}
+		p.pos++
+		var patterns []operand
+		for {
+			if tok, ok := p.peek(); ok && tok.kind == tokRBracket {
+				break
+			}
+			pattern, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, pattern)
+			if tok, ok := p.peek(); ok && tok.kind == tokComma {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if tok, ok := p.peek(); !ok || tok.kind != tokRBracket {
+			return nil, fmt.Errorf("expected ']' to close 'in' list")
+		}
+		p.pos++
+		return inNode{left: left, patterns: patterns}, nil
+	default:
+		return nil, fmt.Errorf("unexpected token %q, expected '==', '!=' or 'in'", tok.text)
+	}
+}
@@ -0,0 +1,56 @@
+package registry
+
+import "testing"
+
+func TestParseStepConditionEval(t *testing.T) {
+	vars := map[string]string{"profile": "aws", "branch": "release-4.16"}
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{"equality true", `profile == "aws"`, true},
+		{"equality false", `profile == "gcp"`, false},
+		{"inequality true", `profile != "gcp"`, true},
+		{"inequality false", `profile != "aws"`, false},
+		{"glob membership matches", `branch in ["release-*", "master"]`, true},
+		{"glob membership no match", `branch in ["main", "master"]`, false},
+		{"negation", `!(profile == "gcp")`, true},
+		{"and with one side false", `profile == "aws" && profile == "gcp"`, false},
+		{"and both true", `profile == "aws" && branch in ["release-*"]`, true},
+		{"or true", `profile == "gcp" || profile == "aws"`, true},
+		{"or false", `profile == "gcp" || branch == "main"`, false},
+		{"parens group or before and", `(profile == "gcp" || profile == "aws") && branch in ["release-*"]`, true},
+		{"unknown identifier resolves empty", `missing == ""`, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cond, err := ParseStepCondition(tc.expr)
+			if err != nil {
+				t.Fatalf("ParseStepCondition(%q): %v", tc.expr, err)
+			}
+			got, err := cond.Eval(vars)
+			if err != nil {
+				t.Fatalf("Eval(%q): %v", tc.expr, err)
+			}
+			if got != tc.want {
+				t.Errorf("Eval(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseStepConditionRejectsMalformedExpressions(t *testing.T) {
+	for _, expr := range []string{
+		`profile ==`,
+		`profile == "aws" &&`,
+		`(profile == "aws"`,
+		`profile in "aws"`,
+		`== "aws"`,
+	} {
+		if _, err := ParseStepCondition(expr); err == nil {
+			t.Errorf("ParseStepCondition(%q): expected an error, got none", expr)
+		}
+	}
+}